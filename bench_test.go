@@ -0,0 +1,63 @@
+package proxyproto
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+// discardConn is a net.Conn backed by a fixed byte slice, used so
+// benchmarks measure header parsing rather than I/O.
+type discardConn struct {
+	*bytes.Reader
+}
+
+func (discardConn) Write(b []byte) (int, error)        { return len(b), nil }
+func (discardConn) Close() error                       { return nil }
+func (discardConn) LocalAddr() net.Addr                { return &net.TCPAddr{} }
+func (discardConn) RemoteAddr() net.Addr               { return &net.TCPAddr{} }
+func (discardConn) SetDeadline(t time.Time) error      { return nil }
+func (discardConn) SetReadDeadline(t time.Time) error  { return nil }
+func (discardConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func BenchmarkCheckPrefixV1(b *testing.B) {
+	raw := []byte("PROXY TCP4 10.0.0.1 10.0.0.2 1000 2000\r\n")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c := newConn(discardConn{bytes.NewReader(raw)}, 0, defaultPool)
+		c.RemoteAddr()
+		c.Close()
+	}
+}
+
+func BenchmarkCheckPrefixV2(b *testing.B) {
+	var buf bytes.Buffer
+	hdr := &Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: TransportStream,
+		SourceAddr:        &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1000},
+		DestAddr:          &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 2000},
+	}
+	if err := WriteProxyHeader(&buf, hdr, 2); err != nil {
+		b.Fatal(err)
+	}
+	raw := buf.Bytes()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c := newConn(discardConn{bytes.NewReader(raw)}, 0, defaultPool)
+		c.RemoteAddr()
+		c.Close()
+	}
+}
+
+func BenchmarkCheckPrefixNotProxied(b *testing.B) {
+	raw := []byte("GET / HTTP/1.1\r\n")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c := newConn(discardConn{bytes.NewReader(raw)}, 0, defaultPool)
+		c.RemoteAddr()
+		c.Close()
+	}
+}
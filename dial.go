@@ -0,0 +1,86 @@
+package proxyproto
+
+import (
+	"context"
+	"io"
+	"net"
+)
+
+// ContextDialer is the subset of net.Dialer's API that Dialer wraps. It is
+// satisfied by *net.Dialer.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Dialer wraps a ContextDialer so that every connection it establishes is
+// prefixed with a PROXY protocol header, making this package usable on the
+// client side: a Go service speaking Dialer can act as the proxy-protocol
+// frontend rather than only the backend that Listener/Conn implement.
+type Dialer struct {
+	// Dialer is the underlying dialer used to establish connections. If
+	// nil, a zero-value net.Dialer is used.
+	Dialer ContextDialer
+
+	// Version is the PROXY protocol version to write: 1 or 2. Defaults
+	// to 1.
+	Version int
+
+	// Header, if set, is called for each dialed connection to build the
+	// Header to write. If nil, the header describes the dialed connection
+	// itself: its local address as the source and its remote address as
+	// the destination. It is ignored when LocalHeader is set.
+	Header func(conn net.Conn) *Header
+
+	// LocalHeader, if true, writes a v2 LOCAL header (no address
+	// information), whatever Version is set to, instead of consulting
+	// Header. This is the
+	// conventional way to mark a connection, such as a health check, as
+	// not actually being proxied.
+	LocalHeader bool
+}
+
+// DialContext dials address over network using the underlying Dialer, then
+// writes a PROXY header as the first bytes of the new connection before
+// returning it.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := d.Dialer
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.writeHeader(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (d *Dialer) writeHeader(conn net.Conn) error {
+	version := d.Version
+	if version == 0 {
+		version = 1
+	}
+	if d.LocalHeader {
+		return WriteLocalHeader(conn)
+	}
+	if d.Header == nil {
+		return WriteProxyHeader(conn, &Header{
+			Version:           version,
+			Command:           PROXY,
+			TransportProtocol: TransportStream,
+			SourceAddr:        conn.LocalAddr(),
+			DestAddr:          conn.RemoteAddr(),
+		}, version)
+	}
+	return WriteProxyHeader(conn, d.Header(conn), version)
+}
+
+// WriteLocalHeader writes a v2 LOCAL header to w: the conventional way to
+// mark a connection, such as a health check, as not actually being
+// proxied, without describing a real client/destination address pair.
+func WriteLocalHeader(w io.Writer) error {
+	return WriteProxyHeader(w, &Header{Version: 2, Command: LOCAL}, 2)
+}
@@ -0,0 +1,112 @@
+package proxyproto
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+)
+
+func TestDialerWritesV1Header(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	serverRead := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		serverRead <- line
+	}()
+
+	d := &Dialer{
+		Version: 1,
+		Header: func(net.Conn) *Header {
+			return &Header{
+				SourceAddr: &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+				DestAddr:   &net.TCPAddr{IP: net.ParseIP("10.2.2.2"), Port: 2000},
+			}
+		},
+	}
+	conn, err := d.DialContext(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("DialContext: %v", err)
+	}
+	defer conn.Close()
+
+	got := <-serverRead
+	want := "PROXY TCP4 10.1.1.1 10.2.2.2 1000 2000\r\n"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestDialerLocalHeader(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	serverHeader := make(chan *Header, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		pc := NewConn(conn, 0)
+		defer pc.Close()
+		serverHeader <- pc.ProxyHeader()
+	}()
+
+	d := &Dialer{Version: 2, LocalHeader: true}
+	conn, err := d.DialContext(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("DialContext: %v", err)
+	}
+	defer conn.Close()
+
+	h := <-serverHeader
+	if h == nil || h.Command != LOCAL {
+		t.Fatalf("expected a LOCAL header, got %#v", h)
+	}
+}
+
+func TestWriteLocalHeader(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	serverHeader := make(chan *Header, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		pc := NewConn(conn, 0)
+		defer pc.Close()
+		serverHeader <- pc.ProxyHeader()
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := WriteLocalHeader(conn); err != nil {
+		t.Fatalf("WriteLocalHeader: %v", err)
+	}
+
+	h := <-serverHeader
+	if h == nil || h.Command != LOCAL || h.Version != 2 {
+		t.Fatalf("expected a v2 LOCAL header, got %#v", h)
+	}
+}
@@ -0,0 +1,29 @@
+package proxyproto
+
+import "errors"
+
+var (
+	// ErrNoProxyProtocol indicates a connection's leading bytes matched
+	// neither the v1 nor the v2 proxy protocol signature. This is the
+	// expected, common outcome on a listener serving a mix of proxied
+	// and unproxied traffic; it exists mainly so callers of HeaderError
+	// can tell it apart from a malformed header sent by a trusted peer.
+	ErrNoProxyProtocol = errors.New("proxyproto: connection does not use the PROXY protocol")
+
+	// ErrMalformedHeader indicates a proxy header was present but could
+	// not be parsed.
+	ErrMalformedHeader = errors.New("proxyproto: malformed proxy protocol header")
+
+	// ErrUnsupportedVersion indicates a v2 header declared a protocol
+	// version other than 2.
+	ErrUnsupportedVersion = errors.New("proxyproto: unsupported proxy protocol version")
+
+	// ErrUnknownAddressFamily indicates a v1 or v2 header declared an
+	// address family this package doesn't understand.
+	ErrUnknownAddressFamily = errors.New("proxyproto: unknown proxy protocol address family")
+
+	// ErrHeaderTimeout indicates ProxyHeaderTimeout elapsed before the
+	// leading v1 or v2 signature bytes were read. A timeout later in the
+	// header is reported as ErrMalformedHeader.
+	ErrHeaderTimeout = errors.New("proxyproto: timed out waiting for proxy protocol header")
+)
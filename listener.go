@@ -0,0 +1,64 @@
+package proxyproto
+
+import (
+	"net"
+	"time"
+)
+
+// Option configures a Listener constructed via NewListener.
+type Option func(*Listener)
+
+// WithHeaderTimeout sets the maximum time to wait to receive the PROXY
+// protocol header before giving up. Zero (the default) means no timeout.
+func WithHeaderTimeout(d time.Duration) Option {
+	return func(l *Listener) {
+		l.ProxyHeaderTimeout = d
+	}
+}
+
+// WithSourceCheck sets the SourceChecker used to decide whether to trust
+// the PROXY header from a given upstream.
+func WithSourceCheck(check SourceChecker) Option {
+	return func(l *Listener) {
+		l.SourceCheck = check
+	}
+}
+
+// WithAllowUnknown allows the v1 "PROXY UNKNOWN" header, falling back to
+// the underlying connection's address.
+func WithAllowUnknown(allow bool) Option {
+	return func(l *Listener) {
+		l.UnknownOK = allow
+	}
+}
+
+// WithRequireProxyHeader closes connections that don't present a PROXY
+// header, instead of the default of passing them through using the raw
+// socket address.
+func WithRequireProxyHeader(require bool) Option {
+	return func(l *Listener) {
+		l.RequireProxyHeader = require
+	}
+}
+
+// WithPolicy sets a Policy governing whether PROXY headers are trusted,
+// ignored or rejected on a per-upstream basis. It supersedes SourceCheck
+// when both are set.
+func WithPolicy(policy Policy) Option {
+	return func(l *Listener) {
+		l.Policy = policy
+	}
+}
+
+// NewListener wraps inner so that its connections are checked for a PROXY
+// protocol header, as configured by opts. It is equivalent to a Listener
+// literal wrapping inner with the options applied, and works with any
+// net.Listener implementation, including non-TCP listeners such as Unix
+// stream sockets.
+func NewListener(inner net.Listener, opts ...Option) net.Listener {
+	l := &Listener{Listener: inner}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
@@ -0,0 +1,105 @@
+package proxyproto
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// dialAndWrite opens a new connection to addr and writes raw, without
+// waiting for a response.
+func dialAndWrite(t *testing.T, addr net.Addr, raw []byte) net.Conn {
+	t.Helper()
+	conn, err := net.Dial(addr.Network(), addr.String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	if len(raw) > 0 {
+		if _, err := conn.Write(raw); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	return conn
+}
+
+func TestListenerRequireProxyHeaderCloses(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inner.Close()
+
+	ln := NewListener(inner, WithRequireProxyHeader(true))
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- c
+	}()
+
+	client := dialAndWrite(t, inner.Addr(), []byte("not a proxy header at all"))
+	defer client.Close()
+
+	conn := <-accepted
+	defer conn.Close()
+
+	buf := make([]byte, 1)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = conn.Read(buf)
+	if err == nil {
+		t.Fatal("expected WithRequireProxyHeader to close connections missing a header")
+	}
+}
+
+// TestListenerUnixSocket exercises the Listener/NewListener path over a
+// real Unix domain socket, as used when fronting a Unix-socket backend
+// (e.g. HAProxy dialing out to a local app over AF_UNIX): the proxied
+// source/dest addresses should come back as *net.UnixAddr end-to-end.
+func TestListenerUnixSocket(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "proxy.sock")
+	inner, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inner.Close()
+
+	ln := NewListener(inner)
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- c
+	}()
+
+	client, err := net.Dial("unix", sockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	hdr := &Header{
+		Command:    PROXY,
+		SourceAddr: &net.UnixAddr{Name: "/var/run/app/client.sock", Net: "unix"},
+		DestAddr:   &net.UnixAddr{Name: "/var/run/app/server.sock", Net: "unix"},
+	}
+	if err := WriteProxyHeader(client, hdr, 2); err != nil {
+		t.Fatalf("WriteProxyHeader: %v", err)
+	}
+
+	conn := <-accepted
+	defer conn.Close()
+
+	remote, ok := conn.RemoteAddr().(*net.UnixAddr)
+	if !ok || remote.Name != "/var/run/app/client.sock" {
+		t.Fatalf("unexpected remote addr: %#v", conn.RemoteAddr())
+	}
+	local, ok := conn.LocalAddr().(*net.UnixAddr)
+	if !ok || local.Name != "/var/run/app/server.sock" {
+		t.Fatalf("unexpected local addr: %#v", conn.LocalAddr())
+	}
+}
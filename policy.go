@@ -0,0 +1,61 @@
+package proxyproto
+
+import "net"
+
+// Decision is the action a Policy directs for a given upstream connection.
+type Decision int
+
+const (
+	// USE trusts the PROXY header from this upstream: Conn will report
+	// the client address taken from the header.
+	USE Decision = iota
+	// IGNORE parses the PROXY header (so the connection isn't left in an
+	// inconsistent state) but discards it, falling back to the
+	// underlying socket address.
+	IGNORE
+	// REJECT closes the connection if it presents a PROXY header.
+	// Connections without one are passed through using the underlying
+	// socket address, as with IGNORE.
+	REJECT
+	// REQUIRE behaves like USE, except connections that don't present a
+	// PROXY header are also closed.
+	REQUIRE
+)
+
+// Policy decides, given the address of a connecting upstream, how its
+// PROXY header (if any) should be handled.
+type Policy func(upstream net.Addr) (Decision, error)
+
+// PolicyFromCIDRs returns a Policy that trusts (USE) PROXY headers from
+// upstreams whose IP falls within one of the trusted CIDR blocks, and
+// REJECTs everyone else. This is the trust model recommended by the PROXY
+// protocol spec and used by most HAProxy/tcpproxy-style deployments: only
+// the load balancer's own address range is allowed to claim a client
+// address on behalf of someone else.
+func PolicyFromCIDRs(trusted []*net.IPNet) Policy {
+	return func(upstream net.Addr) (Decision, error) {
+		ip := addrIP(upstream)
+		if ip != nil {
+			for _, n := range trusted {
+				if n.Contains(ip) {
+					return USE, nil
+				}
+			}
+		}
+		return REJECT, nil
+	}
+}
+
+// addrIP extracts the IP from a net.Addr, if it has one.
+func addrIP(a net.Addr) net.IP {
+	switch addr := a.(type) {
+	case *net.TCPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	case *net.IPAddr:
+		return addr.IP
+	default:
+		return nil
+	}
+}
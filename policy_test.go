@@ -0,0 +1,174 @@
+package proxyproto
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPolicyFromCIDRs(t *testing.T) {
+	_, trustedNet, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := PolicyFromCIDRs([]*net.IPNet{trustedNet})
+
+	decision, err := policy(&net.TCPAddr{IP: net.ParseIP("10.1.2.3")})
+	if err != nil || decision != USE {
+		t.Fatalf("trusted upstream: got (%v, %v), want (USE, nil)", decision, err)
+	}
+
+	decision, err = policy(&net.TCPAddr{IP: net.ParseIP("192.168.1.1")})
+	if err != nil || decision != REJECT {
+		t.Fatalf("untrusted upstream: got (%v, %v), want (REJECT, nil)", decision, err)
+	}
+}
+
+func TestListenerPolicyUse(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inner.Close()
+
+	ln := NewListener(inner, WithPolicy(func(net.Addr) (Decision, error) { return USE, nil }))
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- c
+	}()
+
+	client := dialAndWrite(t, inner.Addr(), []byte("PROXY TCP4 10.9.9.9 10.8.8.8 1111 2222\r\n"))
+	defer client.Close()
+
+	conn := <-accepted
+	defer conn.Close()
+	if got := conn.RemoteAddr().String(); got != "10.9.9.9:1111" {
+		t.Fatalf("USE should trust the header, got remote addr %s", got)
+	}
+}
+
+func TestListenerPolicyIgnore(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inner.Close()
+
+	ln := NewListener(inner, WithPolicy(func(net.Addr) (Decision, error) { return IGNORE, nil }))
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- c
+	}()
+
+	client := dialAndWrite(t, inner.Addr(), []byte("PROXY TCP4 10.9.9.9 10.8.8.8 1111 2222\r\n"))
+	defer client.Close()
+
+	conn := <-accepted
+	defer conn.Close()
+	if got := conn.RemoteAddr().String(); got == "10.9.9.9:1111" {
+		t.Fatalf("IGNORE should not trust the header, got remote addr %s", got)
+	}
+}
+
+func TestListenerPolicyRejectClosesOnHeader(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inner.Close()
+
+	ln := NewListener(inner, WithPolicy(func(net.Addr) (Decision, error) { return REJECT, nil }))
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- c
+	}()
+
+	client := dialAndWrite(t, inner.Addr(), []byte("PROXY TCP4 10.9.9.9 10.8.8.8 1111 2222\r\n"))
+	defer client.Close()
+
+	conn := <-accepted
+	defer conn.Close()
+
+	buf := make([]byte, 1)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = conn.Read(buf)
+	if err == nil {
+		t.Fatal("expected REJECT to close the connection once a header is seen")
+	}
+}
+
+// TestListenerPolicyRejectClosesOnUnknownHeader guards against a
+// regression where a REJECT policy degraded to IGNORE for upstreams
+// that sent "PROXY UNKNOWN" instead of a full header: UNKNOWN is still
+// attacker-controlled input from an untrusted source and must be
+// rejected the same way a full header is.
+func TestListenerPolicyRejectClosesOnUnknownHeader(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inner.Close()
+
+	ln := NewListener(inner, WithPolicy(func(net.Addr) (Decision, error) { return REJECT, nil }), WithAllowUnknown(true))
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- c
+	}()
+
+	client := dialAndWrite(t, inner.Addr(), []byte("PROXY UNKNOWN\r\n"))
+	defer client.Close()
+
+	conn := <-accepted
+	defer conn.Close()
+
+	buf := make([]byte, 1)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = conn.Read(buf)
+	if err == nil {
+		t.Fatal("expected REJECT to close the connection on PROXY UNKNOWN, not degrade to IGNORE")
+	}
+}
+
+func TestListenerPolicyRejectAllowsHeaderlessConn(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inner.Close()
+
+	ln := NewListener(inner, WithPolicy(func(net.Addr) (Decision, error) { return REJECT, nil }))
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- c
+	}()
+
+	client := dialAndWrite(t, inner.Addr(), []byte("hello, not a proxy header\n"))
+	defer client.Close()
+
+	conn := <-accepted
+	defer conn.Close()
+	if err := conn.(*Conn).HeaderError(); !errors.Is(err, ErrNoProxyProtocol) {
+		t.Fatalf("expected ErrNoProxyProtocol, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package proxyproto
+
+import (
+	"bufio"
+	"sync"
+)
+
+// maxV1HeaderLen is the longest possible v1 header line, per the PROXY
+// protocol spec: "PROXY UNKNOWN " followed by two full IPv6 addresses, two
+// ports and a trailing CRLF.
+const maxV1HeaderLen = 107
+
+// bufReaderSize is the size used for each Conn's bufio.Reader: large
+// enough to hold the longest possible v1 header, or the fixed portion of
+// a v2 header, without ever needing to grow.
+const bufReaderSize = maxV1HeaderLen
+
+// BufferPool lets embedders supply their own pool of bufio.Reader
+// instances backing each accepted Conn's PROXY header detection, instead
+// of the package's default sync.Pool-backed one. Implementations must be
+// safe for concurrent use.
+type BufferPool interface {
+	Get() *bufio.Reader
+	Put(*bufio.Reader)
+}
+
+type defaultBufferPool struct {
+	pool sync.Pool
+}
+
+func newDefaultBufferPool() *defaultBufferPool {
+	return &defaultBufferPool{
+		pool: sync.Pool{
+			New: func() interface{} {
+				return bufio.NewReaderSize(nil, bufReaderSize)
+			},
+		},
+	}
+}
+
+func (d *defaultBufferPool) Get() *bufio.Reader {
+	return d.pool.Get().(*bufio.Reader)
+}
+
+func (d *defaultBufferPool) Put(r *bufio.Reader) {
+	r.Reset(nil)
+	d.pool.Put(r)
+}
+
+var defaultPool = newDefaultBufferPool()
@@ -3,10 +3,10 @@ package proxyproto
 import (
 	"bufio"
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"strconv"
 	"strings"
@@ -50,6 +50,30 @@ type Listener struct {
 	ProxyHeaderTimeout time.Duration
 	SourceCheck        SourceChecker
 	UnknownOK          bool // allow PROXY UNKNOWN
+
+	// RequireProxyHeader closes connections that don't present a PROXY
+	// header, instead of the default of passing them through using the
+	// raw socket address. See WithRequireProxyHeader.
+	RequireProxyHeader bool
+
+	// Policy, if set, supersedes SourceCheck: it decides per-upstream
+	// whether to trust, ignore or reject a connection's PROXY header.
+	// See WithPolicy.
+	Policy Policy
+
+	// BufferPool, if set, supplies the bufio.Reader instances used to
+	// buffer each accepted Conn's PROXY header detection. Defaults to a
+	// package-internal sync.Pool-backed implementation.
+	BufferPool BufferPool
+
+	// ErrorHandler, if set, is invoked with the underlying connection and
+	// error whenever RemoteAddr, LocalAddr, ProxySourceAddr, ProxyHeader
+	// or HeaderError lazily discover a malformed or rejected proxy header.
+	// Read and WriteTo return such an error to their caller instead of
+	// invoking ErrorHandler. The default is a no-op; set this to log or
+	// alert on malformed headers instead of silently falling back to the
+	// raw socket address.
+	ErrorHandler func(net.Conn, error)
 }
 
 // Conn is used to wrap and underlying connection which
@@ -58,14 +82,22 @@ type Listener struct {
 type Conn struct {
 	bufReader *bufio.Reader
 	conn      net.Conn
-	dstAddr   *net.TCPAddr
-	srcAddr   *net.TCPAddr
+	dstAddr   net.Addr
+	srcAddr   net.Addr
+	// header is the fully parsed proxy header (v1 or v2), if one was
+	// present. See ProxyHeader.
+	header *Header
 	// Any error encountered while reading the proxyproto header
 	proxyErr           error
 	useConnAddr        bool
+	requireHeader      bool
+	rejectHeader       bool
 	once               sync.Once
+	closeOnce          sync.Once
 	proxyHeaderTimeout time.Duration
 	unknownOK          bool
+	pool               BufferPool
+	errorHandler       func(net.Conn, error)
 }
 
 // Accept waits for and returns the next connection to the listener.
@@ -75,8 +107,29 @@ func (p *Listener) Accept() (net.Conn, error) {
 	if err != nil {
 		return nil, err
 	}
-	var useConnAddr bool
-	if p.SourceCheck != nil {
+	var useConnAddr, requireHeader, rejectHeader bool
+	switch {
+	case p.Policy != nil:
+		decision, err := p.Policy(conn.RemoteAddr())
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		switch decision {
+		case IGNORE:
+			useConnAddr = true
+		case REJECT:
+			// We don't yet know whether this upstream will actually
+			// send a PROXY header; fall back to the raw conn address
+			// like IGNORE, but close the connection if a header does
+			// show up, since it can only be forged.
+			useConnAddr = true
+			rejectHeader = true
+		case REQUIRE:
+			requireHeader = true
+		case USE:
+		}
+	case p.SourceCheck != nil:
 		allowed, err := p.SourceCheck(conn.RemoteAddr())
 		if err != nil {
 			return nil, err
@@ -85,9 +138,19 @@ func (p *Listener) Accept() (net.Conn, error) {
 			useConnAddr = true
 		}
 	}
-	newConn := NewConn(conn, p.ProxyHeaderTimeout)
+	if p.RequireProxyHeader {
+		requireHeader = true
+	}
+	pool := p.BufferPool
+	if pool == nil {
+		pool = defaultPool
+	}
+	newConn := newConn(conn, p.ProxyHeaderTimeout, pool)
 	newConn.useConnAddr = useConnAddr
 	newConn.unknownOK = p.UnknownOK
+	newConn.requireHeader = requireHeader
+	newConn.rejectHeader = rejectHeader
+	newConn.errorHandler = p.ErrorHandler
 	return newConn, nil
 }
 
@@ -104,12 +167,26 @@ func (p *Listener) Addr() net.Addr {
 // NewConn is used to wrap a net.Conn that may be speaking
 // the proxy protocol into a proxyproto.Conn
 func NewConn(conn net.Conn, timeout time.Duration) *Conn {
-	pConn := &Conn{
-		bufReader:          bufio.NewReader(conn),
+	return newConn(conn, timeout, nil)
+}
+
+// newConn is the shared Conn constructor. If pool is non-nil, its
+// bufio.Reader is used to back the connection and returned to the pool on
+// Close, instead of allocating a fresh one.
+func newConn(conn net.Conn, timeout time.Duration, pool BufferPool) *Conn {
+	var br *bufio.Reader
+	if pool != nil {
+		br = pool.Get()
+		br.Reset(conn)
+	} else {
+		br = bufio.NewReaderSize(conn, bufReaderSize)
+	}
+	return &Conn{
+		bufReader:          br,
 		conn:               conn,
 		proxyHeaderTimeout: timeout,
+		pool:               pool,
 	}
-	return pConn
 }
 
 // Read is check for the proxy protocol header when doing
@@ -145,6 +222,11 @@ func (p *Conn) Write(b []byte) (int, error) {
 }
 
 func (p *Conn) Close() error {
+	p.closeOnce.Do(func() {
+		if p.pool != nil {
+			p.pool.Put(p.bufReader)
+		}
+	})
 	return p.conn.Close()
 }
 
@@ -187,6 +269,32 @@ func (p *Conn) ProxySourceAddr() (net.Addr, error) {
 	return p.srcAddr, p.proxyErr
 }
 
+// ProxyHeader returns the fully parsed proxy protocol header (v1 or v2),
+// or nil if the connection isn't using the proxy protocol or its header
+// could not be read, parsed or accepted. This call will read the proxy
+// header if it hasn't been read yet, and thus using a Deadline is
+// recommended if this is called before Read().
+func (p *Conn) ProxyHeader() *Header {
+	p.checkPrefixOnce()
+	return p.header
+}
+
+// HeaderError returns the error encountered while parsing the proxy
+// header, if any. This call will read the proxy header if it hasn't been
+// read yet, and thus using a Deadline is recommended if this is called
+// before Read().
+//
+// A connection that simply isn't speaking the proxy protocol reports
+// ErrNoProxyProtocol here; most callers should treat that as the expected
+// "fall through to the raw socket address" case, and reserve alerting for
+// other errors: a malformed header, a header rejected by the Policy
+// (ErrInvalidUpstream), a timeout (ErrHeaderTimeout), or a failure to read
+// from the connection.
+func (p *Conn) HeaderError() error {
+	p.checkPrefixOnce()
+	return p.proxyErr
+}
+
 func (p *Conn) SetDeadline(t time.Time) error {
 	return p.conn.SetDeadline(t)
 }
@@ -202,13 +310,18 @@ func (p *Conn) SetWriteDeadline(t time.Time) error {
 func (p *Conn) checkPrefixOnce() {
 	p.once.Do(func() {
 		if err := p.checkPrefix(); err != nil && err != io.EOF {
-			log.Printf("[ERR] Failed to read proxy prefix: %v", err)
+			if p.errorHandler != nil {
+				p.errorHandler(p.conn, err)
+			}
 			p.Close()
 			p.bufReader = bufio.NewReader(p.conn)
 		}
 	})
 }
 
+// checkPrefix peeks the first byte of the connection to decide whether
+// it's speaking the v1 (human-readable) or v2 (binary) proxy protocol,
+// then dispatches to the appropriate parser.
 func (p *Conn) checkPrefix() error {
 	if p.proxyHeaderTimeout != 0 {
 		readDeadLine := time.Now().Add(p.proxyHeaderTimeout)
@@ -216,30 +329,126 @@ func (p *Conn) checkPrefix() error {
 		defer p.conn.SetReadDeadline(time.Time{})
 	}
 
-	// Incrementally check each byte of the prefix
-	for i := 1; i <= prefixLen; i++ {
-		inp, err := p.bufReader.Peek(i)
+	lead, err := p.bufReader.Peek(1)
+	if err != nil {
+		if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
+			p.proxyErr = fmt.Errorf("%w: %v", ErrHeaderTimeout, err)
+			return nil
+		}
+		p.proxyErr = fmt.Errorf("error while trying to read proxy header: %w", err)
+		return err
+	}
 
-		if err != nil {
-			p.proxyErr = fmt.Errorf("error while trying to read proxy header: %w", err)
-			if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
-				return nil
-			} else {
-				return err
-			}
+	switch lead[0] {
+	case v2Signature[0]:
+		return p.checkPrefixV2()
+	case prefix[0]:
+		return p.checkPrefixV1()
+	default:
+		// The first byte alone already rules out both v1 and v2; don't
+		// block waiting for more bytes that will never turn this into a
+		// proxy header.
+		p.proxyErr = ErrNoProxyProtocol
+		if p.requireHeader {
+			p.conn.Close()
+			return p.proxyErr
 		}
+		return nil
+	}
+}
 
-		// Check for a prefix mis-match, quit early
-		if !bytes.Equal(inp, prefix[:i]) {
-			p.proxyErr = fmt.Errorf("connection read did not match proxy header")
+// checkPrefixV2 parses a v2 binary proxy header. It assumes the caller
+// has already peeked at least one byte matching v2Signature[0].
+func (p *Conn) checkPrefixV2() error {
+	lead, err := p.bufReader.Peek(v2PrefixLen)
+	if err != nil {
+		if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
+			p.proxyErr = fmt.Errorf("%w: %v", ErrHeaderTimeout, err)
 			return nil
 		}
+		p.proxyErr = fmt.Errorf("error while trying to read proxy v2 header: %w", err)
+		return err
+	}
+	if !bytes.Equal(lead[:len(v2Signature)], v2Signature) {
+		// First byte matched, but the rest of the signature didn't;
+		// this isn't actually a proxy protocol connection.
+		p.proxyErr = ErrNoProxyProtocol
+		if p.requireHeader {
+			p.conn.Close()
+			return p.proxyErr
+		}
+		return nil
+	}
+
+	verCmd := lead[12]
+	famTrans := lead[13]
+	length := int(binary.BigEndian.Uint16(lead[14:16]))
+
+	if _, err := p.bufReader.Discard(v2PrefixLen); err != nil {
+		p.conn.Close()
+		p.proxyErr = fmt.Errorf("%w: error discarding header prefix: %v", ErrMalformedHeader, err)
+		return p.proxyErr
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(p.bufReader, body); err != nil {
+		p.conn.Close()
+		p.proxyErr = fmt.Errorf("%w: error reading header body: %v", ErrMalformedHeader, err)
+		return p.proxyErr
+	}
+
+	header, err := parseV2Header(verCmd, famTrans, body)
+	if err != nil {
+		p.conn.Close()
+		p.proxyErr = err
+		return err
+	}
+	if p.rejectHeader {
+		p.conn.Close()
+		p.proxyErr = fmt.Errorf("%w: proxy header present from untrusted upstream", ErrInvalidUpstream)
+		return p.proxyErr
+	}
+	p.header = header
+
+	if header.Command == LOCAL {
+		// LOCAL carries no address information; fall back to the
+		// underlying socket addresses.
+		p.useConnAddr = true
+		return nil
+	}
+
+	p.srcAddr = header.SourceAddr
+	p.dstAddr = header.DestAddr
+	return nil
+}
+
+func (p *Conn) checkPrefixV1() error {
+	// A single peek is enough: the dispatcher in checkPrefix already
+	// confirmed the first byte matches, so a mismatch anywhere in the
+	// rest of the prefix means this isn't a proxied connection.
+	inp, err := p.bufReader.Peek(prefixLen)
+	if err != nil {
+		if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
+			p.proxyErr = fmt.Errorf("%w: %v", ErrHeaderTimeout, err)
+			return nil
+		}
+		p.proxyErr = fmt.Errorf("error while trying to read proxy header: %w", err)
+		return err
+	}
+
+	if !bytes.Equal(inp, prefix) {
+		p.proxyErr = ErrNoProxyProtocol
+		if p.requireHeader {
+			p.conn.Close()
+			return p.proxyErr
+		}
+		return nil
 	}
 
 	// Read the header line
 	header, err := p.bufReader.ReadString('\n')
 	if err != nil {
-		p.proxyErr = fmt.Errorf("error reading first proxyheader line: %w", err)
+		p.proxyErr = fmt.Errorf("%w: error reading first proxy header line: %v", ErrMalformedHeader, err)
 		p.conn.Close()
 		return err
 	}
@@ -251,7 +460,7 @@ func (p *Conn) checkPrefix() error {
 	parts := strings.Split(header, " ")
 	if len(parts) < 2 {
 		p.conn.Close()
-		p.proxyErr = fmt.Errorf("invalid header line: %s", header)
+		p.proxyErr = fmt.Errorf("%w: invalid header line: %s", ErrMalformedHeader, header)
 		return p.proxyErr
 	}
 
@@ -260,7 +469,12 @@ func (p *Conn) checkPrefix() error {
 	case "UNKNOWN":
 		if !p.unknownOK || len(parts) != 2 {
 			p.conn.Close()
-			p.proxyErr = fmt.Errorf("invalid UNKNOWN header line: %s", header)
+			p.proxyErr = fmt.Errorf("%w: invalid UNKNOWN header line: %s", ErrMalformedHeader, header)
+			return p.proxyErr
+		}
+		if p.rejectHeader {
+			p.conn.Close()
+			p.proxyErr = fmt.Errorf("%w: proxy header present from untrusted upstream", ErrInvalidUpstream)
 			return p.proxyErr
 		}
 		p.useConnAddr = true
@@ -269,13 +483,13 @@ func (p *Conn) checkPrefix() error {
 	case "TCP6":
 	default:
 		p.conn.Close()
-		p.proxyErr = fmt.Errorf("Unhandled address type: %s", parts[1])
+		p.proxyErr = fmt.Errorf("%w: unhandled address type: %s", ErrUnknownAddressFamily, parts[1])
 		return p.proxyErr
 	}
 
 	if len(parts) != 6 {
 		p.conn.Close()
-		p.proxyErr = fmt.Errorf("Invalid header line (should have 6 parts): %s", header)
+		p.proxyErr = fmt.Errorf("%w: header line should have 6 parts: %s", ErrMalformedHeader, header)
 		return p.proxyErr
 	}
 
@@ -283,13 +497,13 @@ func (p *Conn) checkPrefix() error {
 	ip := net.ParseIP(parts[2])
 	if ip == nil {
 		p.conn.Close()
-		p.proxyErr = fmt.Errorf("Invalid source ip: %s", parts[2])
+		p.proxyErr = fmt.Errorf("%w: invalid source ip: %s", ErrMalformedHeader, parts[2])
 		return p.proxyErr
 	}
 	port, err := strconv.Atoi(parts[4])
 	if err != nil {
 		p.conn.Close()
-		p.proxyErr = fmt.Errorf("Invalid source port: %s", parts[4])
+		p.proxyErr = fmt.Errorf("%w: invalid source port: %s", ErrMalformedHeader, parts[4])
 		return p.proxyErr
 	}
 	p.srcAddr = &net.TCPAddr{IP: ip, Port: port}
@@ -298,16 +512,30 @@ func (p *Conn) checkPrefix() error {
 	ip = net.ParseIP(parts[3])
 	if ip == nil {
 		p.conn.Close()
-		p.proxyErr = fmt.Errorf("Invalid destination ip: %s", parts[3])
+		p.proxyErr = fmt.Errorf("%w: invalid destination ip: %s", ErrMalformedHeader, parts[3])
 		return p.proxyErr
 	}
 	port, err = strconv.Atoi(parts[5])
 	if err != nil {
 		p.conn.Close()
-		p.proxyErr = fmt.Errorf("Invalid destination port: %s", parts[5])
+		p.proxyErr = fmt.Errorf("%w: invalid destination port: %s", ErrMalformedHeader, parts[5])
 		return p.proxyErr
 	}
 	p.dstAddr = &net.TCPAddr{IP: ip, Port: port}
 
+	if p.rejectHeader {
+		p.conn.Close()
+		p.proxyErr = fmt.Errorf("%w: proxy header present from untrusted upstream", ErrInvalidUpstream)
+		return p.proxyErr
+	}
+
+	p.header = &Header{
+		Version:           1,
+		Command:           PROXY,
+		TransportProtocol: TransportStream,
+		SourceAddr:        p.srcAddr,
+		DestAddr:          p.dstAddr,
+	}
+
 	return nil
 }
@@ -0,0 +1,91 @@
+package proxyproto
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestCheckPrefixShortCircuitsOnFirstByte guards against a regression
+// where a connection whose first byte matched neither the v1 nor v2
+// signature would still block on a multi-byte Peek waiting for bytes
+// that would never arrive.
+func TestCheckPrefixShortCircuitsOnFirstByte(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		client.Write([]byte("G"))
+		// Deliberately don't write anything else or close the
+		// connection: a correct implementation must not need more
+		// than this one byte to decide it isn't a proxy header.
+	}()
+
+	pc := NewConn(server, 0)
+	defer pc.Close()
+
+	done := make(chan net.Addr, 1)
+	go func() { done <- pc.RemoteAddr() }()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("RemoteAddr blocked instead of short-circuiting on a non-matching first byte")
+	}
+}
+
+func TestCheckPrefixV1(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte("PROXY TCP4 10.1.1.1 10.2.2.2 1000 2000\r\nhello"))
+
+	pc := NewConn(server, 0)
+	defer pc.Close()
+
+	if got := pc.RemoteAddr().String(); got != "10.1.1.1:1000" {
+		t.Fatalf("got remote addr %s, want 10.1.1.1:1000", got)
+	}
+}
+
+func TestHeaderErrorNoProxyProtocol(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte("GET / HTTP/1.1\r\n"))
+
+	pc := NewConn(server, 0)
+	defer pc.Close()
+
+	pc.RemoteAddr()
+	if pc.HeaderError() != ErrNoProxyProtocol {
+		t.Fatalf("got %v, want ErrNoProxyProtocol", pc.HeaderError())
+	}
+}
+
+// TestCloseIdempotent guards against a regression where a double Close
+// (e.g. a deferred Close plus an explicit one on an error path) returned
+// the same pooled *bufio.Reader to the BufferPool twice, letting a later
+// Get() for an unrelated connection receive a reader that's still
+// reachable from this Conn.
+func TestCloseIdempotent(t *testing.T) {
+	pool := newDefaultBufferPool()
+	server, client := net.Pipe()
+	defer client.Close()
+
+	pc := newConn(server, 0, pool)
+	if err := pc.Close(); err != nil {
+		t.Fatalf("first Close: %v", err)
+	}
+	if err := pc.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+
+	seen := make(map[*bufio.Reader]bool)
+	for i := 0; i < 2; i++ {
+		br := pool.Get()
+		if seen[br] {
+			t.Fatalf("pool.Get() returned the same *bufio.Reader twice after a double Close")
+		}
+		seen[br] = true
+	}
+}
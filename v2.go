@@ -0,0 +1,239 @@
+package proxyproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// Command is the PROXY protocol v2 command: either LOCAL (the connection
+// was established on purpose by the proxy, e.g. for a health check, and
+// carries no address information) or PROXY (address information for a
+// proxied connection follows).
+type Command byte
+
+const (
+	LOCAL Command = 0x0
+	PROXY Command = 0x1
+)
+
+func (c Command) String() string {
+	switch c {
+	case LOCAL:
+		return "LOCAL"
+	case PROXY:
+		return "PROXY"
+	default:
+		return fmt.Sprintf("Command(%#x)", byte(c))
+	}
+}
+
+// AddressFamily is the address family of a v2 header, carried in the high
+// nibble of the family/transport byte.
+type AddressFamily byte
+
+const (
+	AFUnspec AddressFamily = 0x0
+	AFInet   AddressFamily = 0x1
+	AFInet6  AddressFamily = 0x2
+	AFUnix   AddressFamily = 0x3
+)
+
+// TransportProtocol is the transport protocol of a v2 header, carried in
+// the low nibble of the family/transport byte.
+type TransportProtocol byte
+
+const (
+	TransportUnspec TransportProtocol = 0x0
+	TransportStream TransportProtocol = 0x1
+	TransportDgram  TransportProtocol = 0x2
+)
+
+// TLVType identifies the kind of data carried by a v2 TLV vector, per
+// section 2.2 of the PROXY protocol spec.
+type TLVType byte
+
+const (
+	PP2TypeALPN      TLVType = 0x01
+	PP2TypeAuthority TLVType = 0x02
+	PP2TypeCRC32C    TLVType = 0x03
+	PP2TypeNoOp      TLVType = 0x04
+	PP2TypeUniqueID  TLVType = 0x05
+	PP2TypeSSL       TLVType = 0x20
+	PP2TypeNetNS     TLVType = 0x30
+
+	// PP2TypeAWS and PP2TypeGCP are vendor-specific TLVs used by AWS
+	// Network Load Balancer and GCP's internal load balancers
+	// respectively. Their value layout is vendor-defined; callers that
+	// care about the contents should parse Value themselves.
+	PP2TypeAWS TLVType = 0xEA
+	PP2TypeGCP TLVType = 0xE0
+)
+
+// Subtypes carried inside the value of a PP2TypeSSL TLV.
+const (
+	PP2SubtypeSSLVersion TLVType = 0x21
+	PP2SubtypeSSLCN      TLVType = 0x22
+	PP2SubtypeSSLCipher  TLVType = 0x23
+	PP2SubtypeSSLSigAlg  TLVType = 0x24
+	PP2SubtypeSSLKeyAlg  TLVType = 0x25
+)
+
+// Client bitfield flags carried in the first byte of a PP2TypeSSL TLV.
+const (
+	PP2ClientSSL      byte = 0x01
+	PP2ClientCertConn byte = 0x02
+	PP2ClientCertSess byte = 0x04
+)
+
+// TLV is a single Type-Length-Value vector, as defined by section 2.2 of
+// the PROXY protocol v2 spec.
+type TLV struct {
+	Type  TLVType
+	Value []byte
+}
+
+// SSLTLV is the decoded form of a PP2TypeSSL TLV's value.
+type SSLTLV struct {
+	Client  byte
+	Verify  uint32
+	SubTLVs []TLV
+}
+
+// SSL decodes the receiver as a PP2TypeSSL TLV. It returns an error if the
+// TLV is not of that type or its value is malformed.
+func (t TLV) SSL() (*SSLTLV, error) {
+	if t.Type != PP2TypeSSL {
+		return nil, fmt.Errorf("proxyproto: TLV type %#x is not PP2TypeSSL", byte(t.Type))
+	}
+	if len(t.Value) < 5 {
+		return nil, fmt.Errorf("%w: SSL TLV value too short: %d bytes", ErrMalformedHeader, len(t.Value))
+	}
+	sub, err := parseTLVs(t.Value[5:])
+	if err != nil {
+		return nil, fmt.Errorf("malformed SSL sub-TLVs: %w", err)
+	}
+	return &SSLTLV{
+		Client:  t.Value[0],
+		Verify:  binary.BigEndian.Uint32(t.Value[1:5]),
+		SubTLVs: sub,
+	}, nil
+}
+
+// Header is a parsed PROXY protocol header, v1 or v2. v1 headers only ever
+// populate Version, Command (always PROXY), TransportProtocol (always
+// TransportStream), SourceAddr and DestAddr; TLVs are a v2-only concept.
+type Header struct {
+	Version           int
+	Command           Command
+	TransportProtocol TransportProtocol
+	SourceAddr        net.Addr
+	DestAddr          net.Addr
+	TLVs              []TLV
+}
+
+// TLV returns the first TLV of the given type present in the header, if
+// any.
+func (h *Header) TLV(t TLVType) (TLV, bool) {
+	for _, tlv := range h.TLVs {
+		if tlv.Type == t {
+			return tlv, true
+		}
+	}
+	return TLV{}, false
+}
+
+var (
+	// v2Signature is the 12 byte magic prefix of every v2 header.
+	v2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+	// v2PrefixLen is the length of the fixed portion of a v2 header: the
+	// signature, the version/command byte, the address family/transport
+	// byte, and the 2 byte address-block length.
+	v2PrefixLen = len(v2Signature) + 4
+)
+
+// Address block sizes, per section 2.5 of the spec.
+const (
+	addrLenInet  = 4 + 4 + 2 + 2
+	addrLenInet6 = 16 + 16 + 2 + 2
+	addrLenUnix  = 108 + 108
+)
+
+// parseV2Header decodes the version/command byte, family/transport byte
+// and trailing address-block-plus-TLVs body of a v2 header that has
+// already had its 12 byte signature stripped and verified.
+func parseV2Header(verCmd, famTrans byte, body []byte) (*Header, error) {
+	version := int(verCmd >> 4)
+	if version != 2 {
+		return nil, fmt.Errorf("%w: v2 header declared version %d", ErrUnsupportedVersion, version)
+	}
+
+	h := &Header{
+		Version:           2,
+		Command:           Command(verCmd & 0x0F),
+		TransportProtocol: TransportProtocol(famTrans & 0x0F),
+	}
+	family := AddressFamily(famTrans >> 4)
+
+	var addrLen int
+	switch family {
+	case AFInet:
+		addrLen = addrLenInet
+	case AFInet6:
+		addrLen = addrLenInet6
+	case AFUnix:
+		addrLen = addrLenUnix
+	case AFUnspec:
+		addrLen = 0
+	default:
+		return nil, fmt.Errorf("%w: %#x", ErrUnknownAddressFamily, byte(family))
+	}
+	if len(body) < addrLen {
+		return nil, fmt.Errorf("%w: address block too short for family %#x", ErrMalformedHeader, byte(family))
+	}
+
+	switch family {
+	case AFInet:
+		h.SourceAddr = &net.TCPAddr{IP: append(net.IP(nil), body[0:4]...), Port: int(binary.BigEndian.Uint16(body[8:10]))}
+		h.DestAddr = &net.TCPAddr{IP: append(net.IP(nil), body[4:8]...), Port: int(binary.BigEndian.Uint16(body[10:12]))}
+	case AFInet6:
+		h.SourceAddr = &net.TCPAddr{IP: append(net.IP(nil), body[0:16]...), Port: int(binary.BigEndian.Uint16(body[32:34]))}
+		h.DestAddr = &net.TCPAddr{IP: append(net.IP(nil), body[16:32]...), Port: int(binary.BigEndian.Uint16(body[34:36]))}
+	case AFUnix:
+		h.SourceAddr = &net.UnixAddr{Net: "unix", Name: nullTerminated(body[0:108])}
+		h.DestAddr = &net.UnixAddr{Net: "unix", Name: nullTerminated(body[108:216])}
+	}
+
+	tlvs, err := parseTLVs(body[addrLen:])
+	if err != nil {
+		return nil, err
+	}
+	h.TLVs = tlvs
+	return h, nil
+}
+
+func nullTerminated(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func parseTLVs(b []byte) ([]TLV, error) {
+	var tlvs []TLV
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return nil, fmt.Errorf("%w: truncated TLV header", ErrMalformedHeader)
+		}
+		typ := TLVType(b[0])
+		l := int(binary.BigEndian.Uint16(b[1:3]))
+		if len(b) < 3+l {
+			return nil, fmt.Errorf("%w: truncated TLV value for type %#x", ErrMalformedHeader, byte(typ))
+		}
+		tlvs = append(tlvs, TLV{Type: typ, Value: b[3 : 3+l]})
+		b = b[3+l:]
+	}
+	return tlvs, nil
+}
@@ -0,0 +1,178 @@
+package proxyproto
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+)
+
+func tcp4Body(t *testing.T, srcPort, dstPort uint16) []byte {
+	t.Helper()
+	body := make([]byte, addrLenInet)
+	copy(body[0:4], net.ParseIP("10.1.1.1").To4())
+	copy(body[4:8], net.ParseIP("10.2.2.2").To4())
+	binary.BigEndian.PutUint16(body[8:10], srcPort)
+	binary.BigEndian.PutUint16(body[10:12], dstPort)
+	return body
+}
+
+func TestParseV2HeaderTCP4(t *testing.T) {
+	verCmd := byte(2)<<4 | byte(PROXY)
+	famTrans := byte(AFInet)<<4 | byte(TransportStream)
+	h, err := parseV2Header(verCmd, famTrans, tcp4Body(t, 1000, 2000))
+	if err != nil {
+		t.Fatalf("parseV2Header: %v", err)
+	}
+	src, ok := h.SourceAddr.(*net.TCPAddr)
+	if !ok || !src.IP.Equal(net.ParseIP("10.1.1.1")) || src.Port != 1000 {
+		t.Fatalf("unexpected source addr: %#v", h.SourceAddr)
+	}
+	dst, ok := h.DestAddr.(*net.TCPAddr)
+	if !ok || !dst.IP.Equal(net.ParseIP("10.2.2.2")) || dst.Port != 2000 {
+		t.Fatalf("unexpected dest addr: %#v", h.DestAddr)
+	}
+}
+
+func TestParseV2HeaderTCP6(t *testing.T) {
+	body := make([]byte, addrLenInet6)
+	copy(body[0:16], net.ParseIP("fe80::1").To16())
+	copy(body[16:32], net.ParseIP("fe80::2").To16())
+	binary.BigEndian.PutUint16(body[32:34], 1000)
+	binary.BigEndian.PutUint16(body[34:36], 2000)
+
+	verCmd := byte(2)<<4 | byte(PROXY)
+	famTrans := byte(AFInet6)<<4 | byte(TransportStream)
+	h, err := parseV2Header(verCmd, famTrans, body)
+	if err != nil {
+		t.Fatalf("parseV2Header: %v", err)
+	}
+	src := h.SourceAddr.(*net.TCPAddr)
+	if !src.IP.Equal(net.ParseIP("fe80::1")) || src.Port != 1000 {
+		t.Fatalf("unexpected source addr: %#v", h.SourceAddr)
+	}
+}
+
+func TestParseV2HeaderUnix(t *testing.T) {
+	body := make([]byte, addrLenUnix)
+	copy(body[0:108], "/tmp/src.sock")
+	copy(body[108:216], "/tmp/dst.sock")
+
+	verCmd := byte(2)<<4 | byte(PROXY)
+	famTrans := byte(AFUnix)<<4 | byte(TransportStream)
+	h, err := parseV2Header(verCmd, famTrans, body)
+	if err != nil {
+		t.Fatalf("parseV2Header: %v", err)
+	}
+	src, ok := h.SourceAddr.(*net.UnixAddr)
+	if !ok || src.Name != "/tmp/src.sock" {
+		t.Fatalf("unexpected source addr: %#v", h.SourceAddr)
+	}
+	dst, ok := h.DestAddr.(*net.UnixAddr)
+	if !ok || dst.Name != "/tmp/dst.sock" {
+		t.Fatalf("unexpected dest addr: %#v", h.DestAddr)
+	}
+}
+
+func TestParseV2HeaderLocal(t *testing.T) {
+	verCmd := byte(2)<<4 | byte(LOCAL)
+	h, err := parseV2Header(verCmd, 0, nil)
+	if err != nil {
+		t.Fatalf("parseV2Header: %v", err)
+	}
+	if h.Command != LOCAL {
+		t.Fatalf("expected LOCAL command, got %v", h.Command)
+	}
+	if h.SourceAddr != nil || h.DestAddr != nil {
+		t.Fatalf("LOCAL header should carry no address, got %#v / %#v", h.SourceAddr, h.DestAddr)
+	}
+}
+
+func TestParseV2HeaderUnsupportedVersion(t *testing.T) {
+	verCmd := byte(1)<<4 | byte(PROXY) // version 1 in a v2-framed header
+	_, err := parseV2Header(verCmd, byte(AFInet)<<4, tcp4Body(t, 1, 2))
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+func TestParseV2HeaderUnknownFamily(t *testing.T) {
+	verCmd := byte(2)<<4 | byte(PROXY)
+	famTrans := byte(0xF) << 4 // not a defined address family
+	_, err := parseV2Header(verCmd, famTrans, tcp4Body(t, 1, 2))
+	if !errors.Is(err, ErrUnknownAddressFamily) {
+		t.Fatalf("expected ErrUnknownAddressFamily, got %v", err)
+	}
+}
+
+func TestParseV2HeaderTruncatedAddress(t *testing.T) {
+	verCmd := byte(2)<<4 | byte(PROXY)
+	famTrans := byte(AFInet)<<4 | byte(TransportStream)
+	_, err := parseV2Header(verCmd, famTrans, tcp4Body(t, 1, 2)[:addrLenInet-1])
+	if !errors.Is(err, ErrMalformedHeader) {
+		t.Fatalf("expected ErrMalformedHeader, got %v", err)
+	}
+}
+
+func TestParseTLVsTruncatedHeader(t *testing.T) {
+	_, err := parseTLVs([]byte{byte(PP2TypeALPN), 0x00})
+	if !errors.Is(err, ErrMalformedHeader) {
+		t.Fatalf("expected ErrMalformedHeader, got %v", err)
+	}
+}
+
+func TestParseTLVsTruncatedValue(t *testing.T) {
+	var b []byte
+	b = append(b, byte(PP2TypeAuthority))
+	var l [2]byte
+	binary.BigEndian.PutUint16(l[:], 10) // claims 10 bytes, provides none
+	b = append(b, l[:]...)
+	_, err := parseTLVs(b)
+	if !errors.Is(err, ErrMalformedHeader) {
+		t.Fatalf("expected ErrMalformedHeader, got %v", err)
+	}
+}
+
+func TestParseTLVsRoundTrip(t *testing.T) {
+	var b []byte
+	appendTLV := func(typ TLVType, value []byte) {
+		var l [2]byte
+		binary.BigEndian.PutUint16(l[:], uint16(len(value)))
+		b = append(b, byte(typ))
+		b = append(b, l[:]...)
+		b = append(b, value...)
+	}
+	appendTLV(PP2TypeALPN, []byte("h2"))
+	appendTLV(PP2TypeAuthority, []byte("example.com"))
+
+	tlvs, err := parseTLVs(b)
+	if err != nil {
+		t.Fatalf("parseTLVs: %v", err)
+	}
+	if len(tlvs) != 2 || string(tlvs[0].Value) != "h2" || string(tlvs[1].Value) != "example.com" {
+		t.Fatalf("unexpected TLVs: %#v", tlvs)
+	}
+}
+
+func TestTLVSSL(t *testing.T) {
+	sub := []byte{byte(PP2SubtypeSSLVersion), 0x00, 0x03, 'T', 'L', 'S'}
+	value := append([]byte{PP2ClientSSL, 0x00, 0x00, 0x00, 0x01}, sub...)
+	tlv := TLV{Type: PP2TypeSSL, Value: value}
+
+	ssl, err := tlv.SSL()
+	if err != nil {
+		t.Fatalf("SSL: %v", err)
+	}
+	if ssl.Client != PP2ClientSSL || ssl.Verify != 1 {
+		t.Fatalf("unexpected SSL TLV: %#v", ssl)
+	}
+	if len(ssl.SubTLVs) != 1 || ssl.SubTLVs[0].Type != PP2SubtypeSSLVersion || string(ssl.SubTLVs[0].Value) != "TLS" {
+		t.Fatalf("unexpected SSL sub-TLVs: %#v", ssl.SubTLVs)
+	}
+}
+
+func TestTLVSSLWrongType(t *testing.T) {
+	if _, err := (TLV{Type: PP2TypeALPN}).SSL(); err == nil {
+		t.Fatal("expected error parsing non-SSL TLV as SSL")
+	}
+}
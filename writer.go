@@ -0,0 +1,138 @@
+package proxyproto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+// WriteProxyHeader writes a PROXY protocol header describing hdr to w,
+// encoded as the given version (1 or 2). It is the client-side
+// counterpart to Listener/Conn: code fronting a proxy-protocol-aware
+// server can use it to prefix a connection with the appropriate header
+// before writing any application data.
+//
+// For v1, if hdr is nil or its SourceAddr/DestAddr aren't *net.TCPAddr,
+// "PROXY UNKNOWN\r\n" is written instead. For v2, a nil hdr produces a
+// LOCAL header with no address block, and a nil SourceAddr produces an
+// AFUnspec header with hdr.Command as given; other address types, or a
+// source and destination of different families, return an error.
+func WriteProxyHeader(w io.Writer, hdr *Header, version int) error {
+	switch version {
+	case 1:
+		return writeV1Header(w, hdr)
+	case 2:
+		return writeV2Header(w, hdr)
+	default:
+		return fmt.Errorf("proxyproto: unsupported proxy protocol version %d", version)
+	}
+}
+
+func writeV1Header(w io.Writer, hdr *Header) error {
+	srcTCP, ok1 := addrTCP(hdr, true)
+	dstTCP, ok2 := addrTCP(hdr, false)
+	if !ok1 || !ok2 {
+		_, err := io.WriteString(w, "PROXY UNKNOWN\r\n")
+		return err
+	}
+	proto := "TCP4"
+	if srcTCP.IP.To4() == nil {
+		proto = "TCP6"
+	}
+	_, err := fmt.Fprintf(w, "PROXY %s %s %s %d %d\r\n", proto, srcTCP.IP.String(), dstTCP.IP.String(), srcTCP.Port, dstTCP.Port)
+	return err
+}
+
+func addrTCP(hdr *Header, src bool) (*net.TCPAddr, bool) {
+	if hdr == nil {
+		return nil, false
+	}
+	a := hdr.DestAddr
+	if src {
+		a = hdr.SourceAddr
+	}
+	tcp, ok := a.(*net.TCPAddr)
+	return tcp, ok
+}
+
+func writeV2Header(w io.Writer, hdr *Header) error {
+	cmd := LOCAL
+	var family AddressFamily
+	var transport TransportProtocol
+	var addr []byte
+
+	if hdr != nil {
+		cmd = hdr.Command
+		transport = hdr.TransportProtocol
+		switch src := hdr.SourceAddr.(type) {
+		case nil:
+			family = AFUnspec
+		case *net.TCPAddr:
+			dst, ok := hdr.DestAddr.(*net.TCPAddr)
+			if !ok {
+				return fmt.Errorf("proxyproto: v2 header has TCP source addr but non-TCP dest addr %T", hdr.DestAddr)
+			}
+			if v4 := src.IP.To4(); v4 != nil {
+				dst4 := dst.IP.To4()
+				if dst4 == nil {
+					return fmt.Errorf("proxyproto: v2 header has IPv4 source addr but non-IPv4 dest addr %s", dst.IP)
+				}
+				family = AFInet
+				addr = make([]byte, addrLenInet)
+				copy(addr[0:4], v4)
+				copy(addr[4:8], dst4)
+				binary.BigEndian.PutUint16(addr[8:10], uint16(src.Port))
+				binary.BigEndian.PutUint16(addr[10:12], uint16(dst.Port))
+			} else {
+				dst6 := dst.IP.To16()
+				if dst.IP.To4() != nil {
+					return fmt.Errorf("proxyproto: v2 header has IPv6 source addr but non-IPv6 dest addr %s", dst.IP)
+				}
+				family = AFInet6
+				addr = make([]byte, addrLenInet6)
+				copy(addr[0:16], src.IP.To16())
+				copy(addr[16:32], dst6)
+				binary.BigEndian.PutUint16(addr[32:34], uint16(src.Port))
+				binary.BigEndian.PutUint16(addr[34:36], uint16(dst.Port))
+			}
+		case *net.UnixAddr:
+			dst, ok := hdr.DestAddr.(*net.UnixAddr)
+			if !ok {
+				return fmt.Errorf("proxyproto: v2 header has unix source addr but non-unix dest addr %T", hdr.DestAddr)
+			}
+			if len(src.Name) > 108 || len(dst.Name) > 108 {
+				return fmt.Errorf("proxyproto: v2 unix address path longer than 108 bytes: %q, %q", src.Name, dst.Name)
+			}
+			family = AFUnix
+			addr = make([]byte, addrLenUnix)
+			copy(addr[0:108], src.Name)
+			copy(addr[108:216], dst.Name)
+		default:
+			return fmt.Errorf("proxyproto: unsupported source address type %T", src)
+		}
+	}
+
+	var tlvBuf []byte
+	if hdr != nil {
+		for _, tlv := range hdr.TLVs {
+			var l [2]byte
+			binary.BigEndian.PutUint16(l[:], uint16(len(tlv.Value)))
+			tlvBuf = append(tlvBuf, byte(tlv.Type))
+			tlvBuf = append(tlvBuf, l[:]...)
+			tlvBuf = append(tlvBuf, tlv.Value...)
+		}
+	}
+
+	buf := make([]byte, 0, v2PrefixLen+len(addr)+len(tlvBuf))
+	buf = append(buf, v2Signature...)
+	buf = append(buf, byte(2)<<4|byte(cmd), byte(family)<<4|byte(transport))
+	var l [2]byte
+	binary.BigEndian.PutUint16(l[:], uint16(len(addr)+len(tlvBuf)))
+	buf = append(buf, l[:]...)
+	buf = append(buf, addr...)
+	buf = append(buf, tlvBuf...)
+
+	_, err := w.Write(buf)
+	return err
+}
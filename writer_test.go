@@ -0,0 +1,112 @@
+package proxyproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+func TestWriteProxyHeaderV1(t *testing.T) {
+	hdr := &Header{
+		SourceAddr: &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		DestAddr:   &net.TCPAddr{IP: net.ParseIP("10.2.2.2"), Port: 2000},
+	}
+	var buf bytes.Buffer
+	if err := WriteProxyHeader(&buf, hdr, 1); err != nil {
+		t.Fatalf("WriteProxyHeader: %v", err)
+	}
+	want := "PROXY TCP4 10.1.1.1 10.2.2.2 1000 2000\r\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteProxyHeaderV1Unknown(t *testing.T) {
+	hdr := &Header{SourceAddr: &net.UnixAddr{Name: "/tmp/x.sock"}, DestAddr: &net.UnixAddr{Name: "/tmp/y.sock"}}
+	var buf bytes.Buffer
+	if err := WriteProxyHeader(&buf, hdr, 1); err != nil {
+		t.Fatalf("WriteProxyHeader: %v", err)
+	}
+	if buf.String() != "PROXY UNKNOWN\r\n" {
+		t.Fatalf("got %q, want PROXY UNKNOWN", buf.String())
+	}
+}
+
+func TestWriteProxyHeaderV2RoundTrip(t *testing.T) {
+	hdr := &Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: TransportStream,
+		SourceAddr:        &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		DestAddr:          &net.TCPAddr{IP: net.ParseIP("10.2.2.2"), Port: 2000},
+		TLVs:              []TLV{{Type: PP2TypeALPN, Value: []byte("h2")}},
+	}
+	var buf bytes.Buffer
+	if err := WriteProxyHeader(&buf, hdr, 2); err != nil {
+		t.Fatalf("WriteProxyHeader: %v", err)
+	}
+
+	raw := buf.Bytes()
+	if !bytes.Equal(raw[:len(v2Signature)], v2Signature) {
+		t.Fatalf("missing v2 signature")
+	}
+	length := int(binary.BigEndian.Uint16(raw[14:16]))
+	got, err := parseV2Header(raw[12], raw[13], raw[16:16+length])
+	if err != nil {
+		t.Fatalf("parseV2Header: %v", err)
+	}
+	if !got.SourceAddr.(*net.TCPAddr).IP.Equal(net.ParseIP("10.1.1.1")) {
+		t.Fatalf("unexpected round-tripped source addr: %#v", got.SourceAddr)
+	}
+	if len(got.TLVs) != 1 || string(got.TLVs[0].Value) != "h2" {
+		t.Fatalf("unexpected round-tripped TLVs: %#v", got.TLVs)
+	}
+}
+
+func TestWriteProxyHeaderV2UnixRoundTrip(t *testing.T) {
+	hdr := &Header{
+		Command:    PROXY,
+		SourceAddr: &net.UnixAddr{Name: "/tmp/src.sock"},
+		DestAddr:   &net.UnixAddr{Name: "/tmp/dst.sock"},
+	}
+	var buf bytes.Buffer
+	if err := WriteProxyHeader(&buf, hdr, 2); err != nil {
+		t.Fatalf("WriteProxyHeader: %v", err)
+	}
+	raw := buf.Bytes()
+	length := int(binary.BigEndian.Uint16(raw[14:16]))
+	got, err := parseV2Header(raw[12], raw[13], raw[16:16+length])
+	if err != nil {
+		t.Fatalf("parseV2Header: %v", err)
+	}
+	if got.SourceAddr.(*net.UnixAddr).Name != "/tmp/src.sock" {
+		t.Fatalf("unexpected round-tripped unix source: %#v", got.SourceAddr)
+	}
+}
+
+func TestWriteProxyHeaderV2UnixPathTooLong(t *testing.T) {
+	hdr := &Header{
+		SourceAddr: &net.UnixAddr{Name: string(make([]byte, 109))},
+		DestAddr:   &net.UnixAddr{Name: "/tmp/dst.sock"},
+	}
+	if err := WriteProxyHeader(&bytes.Buffer{}, hdr, 2); err == nil {
+		t.Fatal("expected error for unix path over 108 bytes")
+	}
+}
+
+func TestWriteProxyHeaderV2MismatchedIPFamily(t *testing.T) {
+	hdr := &Header{
+		SourceAddr: &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		DestAddr:   &net.TCPAddr{IP: net.ParseIP("fe80::1"), Port: 2000},
+	}
+	if err := WriteProxyHeader(&bytes.Buffer{}, hdr, 2); err == nil {
+		t.Fatal("expected error for mismatched IPv4/IPv6 source and dest")
+	}
+}
+
+func TestWriteProxyHeaderUnsupportedVersion(t *testing.T) {
+	if err := WriteProxyHeader(&bytes.Buffer{}, &Header{}, 3); err == nil {
+		t.Fatal("expected error for unsupported version")
+	}
+}